@@ -50,6 +50,16 @@ var cronFieldTypes = []fieldType{
 	dayOfWeek,
 }
 
+// Indices into expression.fields, mirroring the order of cronFieldTypes.
+const (
+	secondField = iota
+	minuteField
+	hourField
+	dayOfMonthField
+	monthField
+	dayOfWeekField
+)
+
 type valueRange struct {
 	MinValue int
 	MaxValue int
@@ -62,9 +72,28 @@ func newValueRange(min int, max int) valueRange {
 	}
 }
 
+// cronFieldBits holds the parsed representation of a single cron field.
+//
+// Most fields are fully described by Bits, a 64-bit mask where bit N means
+// "value N is allowed". The remaining flags capture the special tokens that
+// cannot be expressed as a plain set of values:
+//
+//   - noSpecificValue is set for "?", meaning this field imposes no
+//     constraint of its own and the other of day-of-month/day-of-week governs.
+//   - lastDom is set for "L" on day-of-month (last day of the month) or for
+//     "<weekday>L" on day-of-week (last occurrence of that weekday); in the
+//     latter case the weekday itself is still recorded as a single bit.
+//   - nearestWeekday is set for "<day>W" on day-of-month; the target day is
+//     recorded as a single bit and the match is the nearest weekday to it.
+//   - nthDow is set for "<weekday>#n" on day-of-week to the occurrence n; the
+//     weekday itself is recorded as a single bit.
 type cronFieldBits struct {
-	Typ  fieldType
-	Bits uint64
+	Typ             fieldType
+	Bits            uint64
+	noSpecificValue bool
+	lastDom         bool
+	nearestWeekday  bool
+	nthDow          int
 }
 
 func newFieldBits(typ fieldType) *cronFieldBits {
@@ -73,8 +102,19 @@ func newFieldBits(typ fieldType) *cronFieldBits {
 	}
 }
 
-const maxAttempts = 366
-const mask = 0xFFFFFFFFFFFFFFFF
+func (field *cronFieldBits) matches(value int) bool {
+	return field.Bits&(uint64(1)<<uint(value)) != 0
+}
+
+// singleValue returns the one value set in Bits, used by the L/W/# tokens
+// which always pin the field down to exactly one day-of-month or weekday.
+func (field *cronFieldBits) singleValue() int {
+	return bits.TrailingZeros64(field.Bits)
+}
+
+// maxYearsAhead bounds how far into the future NextTime will search before
+// giving up and reporting that the expression never fires again.
+const maxYearsAhead = 5
 
 type CronExpression struct {
 	fields []*cronFieldBits
@@ -86,57 +126,196 @@ func newCronExpression() *CronExpression {
 	}
 }
 
+// NextTime returns the first instant strictly after t at which the
+// expression fires, or the zero time.Time if no such instant exists within
+// maxYearsAhead years.
 func (expression *CronExpression) NextTime(t time.Time) time.Time {
-
 	t = t.Add(1*time.Second - time.Duration(t.Nanosecond())*time.Nanosecond)
+	return expression.next(t)
+}
 
-	for i := 0; i < maxAttempts; i++ {
-		result := expression.next(t)
+func (expression *CronExpression) next(t time.Time) time.Time {
+	yearLimit := t.Year() + maxYearsAhead
 
-		if result.IsZero() || result.Equal(t) {
-			return result
-		}
+	secondBits := expression.fields[secondField]
+	minuteBits := expression.fields[minuteField]
+	hourBits := expression.fields[hourField]
+	monthBits := expression.fields[monthField]
 
-		t = result
-	}
+WRAP:
+	for t.Year() <= yearLimit {
+		for !monthBits.matches(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+			t = t.AddDate(0, 1, 0)
 
-	return time.Time{}
-}
+			if t.Year() > yearLimit {
+				return time.Time{}
+			}
+		}
 
-func (expression *CronExpression) next(t time.Time) time.Time {
-	for _, field := range expression.fields {
+		for !expression.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+			t = t.AddDate(0, 0, 1)
+
+			if t.Day() == 1 {
+				continue WRAP
+			}
+		}
 
-		temp := t
-		current := getTimeValue(temp, field.Typ.Field)
+		// Hour, minute and second are rolled forward by zeroing the
+		// finer-grained fields once and then repeatedly adding a duration.
+		// Reconstructing the wall-clock time via time.Date on every
+		// iteration would re-snap to the first occurrence of an ambiguous
+		// hour on a DST fall-back and never advance; adding a duration
+		// instead always moves strictly forward in absolute time, which
+		// also correctly skips the hour a DST spring-forward removes.
+		addedHour := false
+
+		for !hourBits.matches(t.Hour()) {
+			if !addedHour {
+				addedHour = true
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+			}
 
-		next := setNextBit(field.Bits, current)
+			t = t.Add(1 * time.Hour)
 
-		if next == -1 {
-			amount := field.Typ.MaxValue - current + 1
-			temp = addTime(temp, field.Typ.Field, amount)
-			next = setNextBit(field.Bits, 0)
+			if t.Hour() == 0 {
+				continue WRAP
+			}
 		}
 
-		if next == current {
-			return t
-		} else {
-			count := 0
-			current := getTimeValue(temp, field.Typ.Field)
-			for ; current != next && count < maxAttempts; count++ {
+		addedMinute := false
 
+		for !minuteBits.matches(t.Minute()) {
+			if !addedMinute {
+				addedMinute = true
+				t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
 			}
 
-			if count >= maxAttempts {
-				return time.Time{}
+			t = t.Add(1 * time.Minute)
+
+			if t.Minute() == 0 {
+				continue WRAP
 			}
+		}
 
+		for !secondBits.matches(t.Second()) {
+			t = t.Add(1 * time.Second)
+
+			if t.Second() == 0 {
+				continue WRAP
+			}
 		}
 
+		return t
 	}
 
 	return time.Time{}
 }
 
+// dayMatches decides whether t's date satisfies the day-of-month and
+// day-of-week fields, applying the traditional cron rule: if both fields
+// impose a constraint, the day matches when either one does; if only one
+// imposes a constraint, that one alone decides.
+func (expression *CronExpression) dayMatches(t time.Time) bool {
+	dom := expression.fields[dayOfMonthField]
+	dow := expression.fields[dayOfWeekField]
+
+	domRestricted := dom.restricted()
+	dowRestricted := dow.restricted()
+
+	switch {
+	case domRestricted && dowRestricted:
+		return domFieldMatches(dom, t) || dowFieldMatches(dow, t)
+	case domRestricted:
+		return domFieldMatches(dom, t)
+	case dowRestricted:
+		return dowFieldMatches(dow, t)
+	default:
+		return true
+	}
+}
+
+// restricted reports whether the field narrows down the set of days at all,
+// i.e. it is not "*" (or "?") and not left unset.
+func (field *cronFieldBits) restricted() bool {
+	if field.noSpecificValue {
+		return false
+	}
+
+	if field.lastDom || field.nearestWeekday || field.nthDow != 0 {
+		return true
+	}
+
+	return field.Bits != fullRange(field.Typ)
+}
+
+func fullRange(typ fieldType) uint64 {
+	return ^(math.MaxUint64 << uint(typ.MaxValue+1)) & (math.MaxUint64 << uint(typ.MinValue))
+}
+
+func domFieldMatches(dom *cronFieldBits, t time.Time) bool {
+	switch {
+	case dom.lastDom && dom.nearestWeekday:
+		return t.Day() == nearestWeekdayDay(t, lastDayOfMonth(t))
+	case dom.lastDom:
+		return t.Day() == lastDayOfMonth(t)
+	case dom.nearestWeekday:
+		return t.Day() == nearestWeekdayDay(t, dom.singleValue())
+	default:
+		return dom.matches(t.Day())
+	}
+}
+
+func dowFieldMatches(dow *cronFieldBits, t time.Time) bool {
+	weekday := int(t.Weekday())
+
+	switch {
+	case dow.lastDom:
+		return weekday == dow.singleValue() && isLastWeekdayOccurrence(t)
+	case dow.nthDow != 0:
+		return weekday == dow.singleValue() && weekdayOccurrence(t) == dow.nthDow
+	default:
+		return dow.matches(weekday)
+	}
+}
+
+func lastDayOfMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+func isLastWeekdayOccurrence(t time.Time) bool {
+	return t.Day()+7 > lastDayOfMonth(t)
+}
+
+func weekdayOccurrence(t time.Time) int {
+	return (t.Day()-1)/7 + 1
+}
+
+// nearestWeekdayDay returns the weekday (Mon-Fri) nearest to the given day
+// of t's month, never crossing into the previous or next month.
+func nearestWeekdayDay(t time.Time, day int) int {
+	candidate := time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, t.Location())
+	lastDay := lastDayOfMonth(t)
+
+	switch candidate.Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+
+		return day - 1
+	case time.Sunday:
+		if day == lastDay {
+			return day - 2
+		}
+
+		return day + 1
+	default:
+		return day
+	}
+}
+
 func ParseCronExpression(expression string) (*CronExpression, error) {
 	if len(expression) == 0 {
 		return nil, errors.New("cron expression must not be empty")
@@ -178,7 +357,78 @@ func parseField(value string, fieldType fieldType) (*cronFieldBits, error) {
 
 	fields := strings.Split(value, ",")
 
+	if len(fields) > 1 && (fieldType.Field == cronFieldDayOfMonth || fieldType.Field == cronFieldDayOfWeek) {
+		for _, field := range fields {
+			if strings.ContainsAny(field, "LW#") {
+				return nil, fmt.Errorf("'%s' cannot be combined with other values in field %s", field, fieldType.Field)
+			}
+		}
+	}
+
 	for _, field := range fields {
+		if field == "?" {
+			if fieldType.Field != cronFieldDayOfMonth && fieldType.Field != cronFieldDayOfWeek {
+				return nil, fmt.Errorf("'?' is not supported in field %s", fieldType.Field)
+			}
+
+			cronFieldBits.noSpecificValue = true
+			continue
+		}
+
+		if fieldType.Field == cronFieldDayOfMonth && (field == "L" || field == "LW") {
+			cronFieldBits.lastDom = true
+			cronFieldBits.nearestWeekday = field == "LW"
+			continue
+		}
+
+		if fieldType.Field == cronFieldDayOfMonth && strings.HasSuffix(field, "W") {
+			day, err := checkValidValue(strings.TrimSuffix(field, "W"), fieldType)
+
+			if err != nil {
+				return nil, err
+			}
+
+			cronFieldBits.Bits |= 1 << day
+			cronFieldBits.nearestWeekday = true
+			continue
+		}
+
+		if fieldType.Field == cronFieldDayOfWeek && strings.HasSuffix(field, "L") {
+			weekday, err := checkValidValue(strings.TrimSuffix(field, "L"), fieldType)
+
+			if err != nil {
+				return nil, err
+			}
+
+			cronFieldBits.Bits |= 1 << weekday
+			cronFieldBits.lastDom = true
+			continue
+		}
+
+		if fieldType.Field == cronFieldDayOfWeek && strings.Contains(field, "#") {
+			parts := strings.SplitN(field, "#", 2)
+
+			weekday, err := checkValidValue(parts[0], fieldType)
+
+			if err != nil {
+				return nil, err
+			}
+
+			n, err := strconv.Atoi(parts[1])
+
+			if err != nil {
+				return nil, fmt.Errorf("the occurrence in field %s must be a number : %s", fieldType.Field, parts[1])
+			}
+
+			if n < 1 || n > 5 {
+				return nil, fmt.Errorf("the occurrence in field %s must be between 1 and 5 : %d", fieldType.Field, n)
+			}
+
+			cronFieldBits.Bits |= 1 << weekday
+			cronFieldBits.nthDow = n
+			continue
+		}
+
 		slashPos := strings.Index(field, "/")
 
 		step := -1
@@ -203,11 +453,11 @@ func parseField(value string, fieldType fieldType) (*cronFieldBits, error) {
 			step, err = strconv.Atoi(stepStr)
 
 			if err != nil {
-				panic(err)
+				return nil, fmt.Errorf("the step in field %s must be a number : %s", fieldType.Field, stepStr)
 			}
 
 			if step <= 0 {
-				panic("step must be 1 or higher")
+				return nil, fmt.Errorf("the step in field %s must be 1 or higher : %d", fieldType.Field, step)
 			}
 
 		} else {
@@ -229,7 +479,7 @@ func parseField(value string, fieldType fieldType) (*cronFieldBits, error) {
 		if valueRange.MinValue == valueRange.MaxValue {
 			cronFieldBits.Bits |= 1 << valueRange.MinValue
 		} else {
-			cronFieldBits.Bits = ^(math.MaxUint64 << (valueRange.MaxValue + 1)) & (math.MaxUint64 << valueRange.MinValue)
+			cronFieldBits.Bits |= ^(math.MaxUint64 << (valueRange.MaxValue + 1)) & (math.MaxUint64 << valueRange.MinValue)
 		}
 	}
 
@@ -266,10 +516,6 @@ func parseRange(value string, fieldType fieldType) (valueRange, error) {
 				return valueRange{}, err
 			}
 
-			if fieldType.Field == cronFieldDayOfWeek && min == 7 {
-				min = 0
-			}
-
 			return newValueRange(min, max), nil
 		}
 	}
@@ -293,8 +539,8 @@ func checkValidValue(value string, fieldType fieldType) (int, error) {
 		return 0, fmt.Errorf("the value in field %s must be number : %s", fieldType.Field, value)
 	}
 
-	if fieldType.Field == cronFieldDayOfWeek && result == 0 {
-		return result, nil
+	if fieldType.Field == cronFieldDayOfWeek && result == 7 {
+		result = 0
 	}
 
 	if result >= fieldType.MinValue && result <= fieldType.MaxValue {
@@ -303,52 +549,3 @@ func checkValidValue(value string, fieldType fieldType) (int, error) {
 
 	return 0, fmt.Errorf("the value in field %s must be between %d and %d", fieldType.Field, fieldType.MinValue, fieldType.MaxValue)
 }
-
-func getTimeValue(t time.Time, field cronField) int {
-
-	switch field {
-	case cronFieldSecond:
-		return t.Second()
-	case cronFieldMinute:
-		return t.Minute()
-	case cronFieldHour:
-		return t.Hour()
-	case cronFieldDayOfMonth:
-		return t.Day()
-	case cronFieldMonth:
-		return int(t.Month())
-	case cronFieldDayOfWeek:
-		return int(t.Weekday())
-	}
-
-	panic("unreachable code")
-}
-
-func addTime(t time.Time, field cronField, value int) time.Time {
-	switch field {
-	case cronFieldSecond:
-		return t.Add(time.Duration(value) * time.Second)
-	case cronFieldMinute:
-		return t.Add(time.Duration(value) * time.Minute)
-	case cronFieldHour:
-		return t.Add(time.Duration(value) * time.Hour)
-	case cronFieldDayOfMonth:
-		return t.AddDate(0, 0, value)
-	case cronFieldMonth:
-		return t.AddDate(0, value, 0)
-	case cronFieldDayOfWeek:
-		return t.AddDate(0, 0, value)
-	}
-
-	panic("unreachable code")
-}
-
-func setNextBit(bitsValue uint64, index int) int {
-	result := bitsValue & (mask << index)
-
-	if result != 0 {
-		return bits.TrailingZeros64(result)
-	}
-
-	return -1
-}
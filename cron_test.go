@@ -0,0 +1,167 @@
+package chrono
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParseCron(t *testing.T, expression string) *CronExpression {
+	t.Helper()
+
+	cronExpression, err := ParseCronExpression(expression)
+	if err != nil {
+		t.Fatalf("ParseCronExpression(%q) returned error: %v", expression, err)
+	}
+
+	return cronExpression
+}
+
+func parseTime(t *testing.T, location *time.Location, layout string, value string) time.Time {
+	t.Helper()
+
+	parsed, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		t.Fatalf("time.ParseInLocation(%q, %q) returned error: %v", layout, value, err)
+	}
+
+	return parsed
+}
+
+const dateTimeLayout = "2006-01-02 15:04:05"
+
+func TestCronExpressionNextTime(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		from       string
+		want       string
+	}{
+		{
+			name:       "every two seconds",
+			expression: "*/2 * * * * *",
+			from:       "2026-01-01 00:00:00",
+			want:       "2026-01-01 00:00:02",
+		},
+		{
+			name:       "comma separated business hours with a lunch gap",
+			expression: "0 0 9-12,14-17 * * *",
+			from:       "2026-01-01 11:00:00",
+			want:       "2026-01-01 12:00:00",
+		},
+		{
+			name:       "comma separated business hours skips the gap",
+			expression: "0 0 9-12,14-17 * * *",
+			from:       "2026-01-01 12:00:00",
+			want:       "2026-01-01 14:00:00",
+		},
+		{
+			name:       "sunday as 7",
+			expression: "0 0 12 ? * 7",
+			from:       "2026-01-01 00:00:00", // Thursday
+			want:       "2026-01-04 12:00:00", // Sunday
+		},
+		{
+			name:       "sunday by name",
+			expression: "0 0 12 ? * SUN",
+			from:       "2026-01-01 00:00:00",
+			want:       "2026-01-04 12:00:00",
+		},
+		{
+			name:       "month boundary carry",
+			expression: "0 0 0 1 * *",
+			from:       "2026-01-31 12:00:00",
+			want:       "2026-02-01 00:00:00",
+		},
+		{
+			name:       "last day of february in a non-leap year",
+			expression: "0 0 0 L * *",
+			from:       "2026-02-01 00:00:00",
+			want:       "2026-02-28 00:00:00",
+		},
+		{
+			name:       "last day of every month including december",
+			expression: "0 0 0 L * *",
+			from:       "2027-12-01 00:00:00",
+			want:       "2027-12-31 00:00:00",
+		},
+		{
+			name:       "nearest weekday to the 15th",
+			expression: "0 0 0 15W * *",
+			from:       "2026-02-01 00:00:00", // 15th is a Sunday
+			want:       "2026-02-16 00:00:00", // nearest weekday is the following Monday
+		},
+		{
+			name:       "third friday of the month",
+			expression: "0 0 0 ? * FRI#3",
+			from:       "2026-01-01 00:00:00",
+			want:       "2026-01-16 00:00:00",
+		},
+		{
+			name:       "last friday of the month",
+			expression: "0 0 0 ? * FRIL",
+			from:       "2026-01-01 00:00:00",
+			want:       "2026-01-30 00:00:00",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cronExpression := mustParseCron(t, test.expression)
+			from := parseTime(t, time.UTC, dateTimeLayout, test.from)
+			want := parseTime(t, time.UTC, dateTimeLayout, test.want)
+
+			got := cronExpression.NextTime(from)
+
+			if !got.Equal(want) {
+				t.Fatalf("NextTime(%s) = %s, want %s", from, got, want)
+			}
+		})
+	}
+}
+
+func TestCronExpressionNextTimeDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("America/New_York not available in this environment: %v", err)
+	}
+
+	t.Run("spring forward skips the nonexistent hour", func(t *testing.T) {
+		cronExpression := mustParseCron(t, "0 30 2 * * *")
+		from := parseTime(t, newYork, dateTimeLayout, "2027-03-13 12:00:00")
+		want := parseTime(t, newYork, dateTimeLayout, "2027-03-15 02:30:00")
+
+		got := cronExpression.NextTime(from)
+
+		if !got.Equal(want) {
+			t.Fatalf("NextTime(%s) = %s, want %s", from, got, want)
+		}
+	})
+
+	t.Run("fall back does not loop forever on the repeated hour", func(t *testing.T) {
+		cronExpression := mustParseCron(t, "0 30 1 * * *")
+		from := parseTime(t, newYork, dateTimeLayout, "2026-10-31 12:00:00")
+		want := parseTime(t, newYork, dateTimeLayout, "2026-11-01 01:30:00")
+
+		got := cronExpression.NextTime(from)
+
+		if !got.Equal(want) {
+			t.Fatalf("NextTime(%s) = %s, want %s", from, got, want)
+		}
+	})
+}
+
+func TestParseCronExpressionErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"60 * * * * *",
+		"* * * * * 8",
+		"*/0 * * * * *",
+	}
+
+	for _, expression := range tests {
+		if _, err := ParseCronExpression(expression); err == nil {
+			t.Errorf("ParseCronExpression(%q) expected an error, got nil", expression)
+		}
+	}
+}
@@ -1,14 +1,29 @@
 package chrono
 
 import (
+	"container/heap"
+	"context"
+	"log"
 	"sync"
 	"time"
 )
 
 type ScheduledExecutor interface {
-	Schedule(task Task, delay time.Duration) *ScheduledTask
-	ScheduleWithFixedDelay(task Task, initialDelay time.Duration, delay time.Duration) *ScheduledTask
-	ScheduleAtWithRate(task Task, initialDelay time.Duration, period time.Duration) *ScheduledTask
+	Schedule(task Task, delay time.Duration, options ...TaskOption) *ScheduledTask
+	ScheduleWithFixedDelay(task Task, initialDelay time.Duration, delay time.Duration, options ...TaskOption) *ScheduledTask
+	ScheduleAtWithRate(task Task, initialDelay time.Duration, period time.Duration, options ...TaskOption) *ScheduledTask
+}
+
+// Logger receives diagnostics the executor cannot otherwise surface, such as
+// a task panicking. It is satisfied by the standard library's *log.Logger.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, args ...any) {
+	log.Printf(format, args...)
 }
 
 type ScheduledTaskExecutor struct {
@@ -16,20 +31,63 @@ type ScheduledTaskExecutor struct {
 	nextSequenceMu    sync.RWMutex
 	timer             *time.Timer
 	taskQueue         ScheduledTaskQueue
-	taskQueueMu       sync.RWMutex
+	taskQueueMu       sync.Mutex
 	newTaskChannel    chan *ScheduledTask
-	removeTaskChannel chan *ScheduledTask
+	cancelTaskChannel chan *ScheduledTask
+	shutdownChannel   chan struct{}
+	shutdownOnce      sync.Once
+	shuttingDown      bool
 	taskWaitGroup     sync.WaitGroup
+	ctx               context.Context
+	logger            Logger
+	dispatch          func(scheduledTask *ScheduledTask)
+}
+
+// ExecutorOption configures a ScheduledTaskExecutor at construction time.
+type ExecutorOption func(executor *ScheduledTaskExecutor)
+
+// WithContext sets the base context.Context passed to every Task the
+// executor runs; WithTimeout/WithDeadline on a task further derive from it.
+// Cancelling ctx does not stop the executor itself, only the tasks that
+// observe ctx.Done().
+func WithContext(ctx context.Context) ExecutorOption {
+	if ctx == nil {
+		panic("context cannot be nil")
+	}
+
+	return func(executor *ScheduledTaskExecutor) {
+		executor.ctx = ctx
+	}
 }
 
-func NewScheduledTaskExecutor() *ScheduledTaskExecutor {
+// WithLogger overrides the Logger used to report a recovered task panic.
+func WithLogger(logger Logger) ExecutorOption {
+	if logger == nil {
+		panic("logger cannot be nil")
+	}
+
+	return func(executor *ScheduledTaskExecutor) {
+		executor.logger = logger
+	}
+}
+
+func NewScheduledTaskExecutor(options ...ExecutorOption) *ScheduledTaskExecutor {
 	executor := &ScheduledTaskExecutor{
 		timer:             time.NewTimer(1 * time.Hour),
 		taskQueue:         make(ScheduledTaskQueue, 0),
 		newTaskChannel:    make(chan *ScheduledTask),
-		removeTaskChannel: make(chan *ScheduledTask),
+		cancelTaskChannel: make(chan *ScheduledTask),
+		shutdownChannel:   make(chan struct{}),
+		ctx:               context.Background(),
+		logger:            stdLogger{},
+	}
+
+	for _, option := range options {
+		option(executor)
 	}
 
+	executor.dispatch = executor.executeAndReschedule
+
 	executor.timer.Stop()
 
 	go executor.run()
@@ -37,39 +95,71 @@ func NewScheduledTaskExecutor() *ScheduledTaskExecutor {
 	return executor
 }
 
-func (executor *ScheduledTaskExecutor) Schedule(task Task, delay time.Duration) *ScheduledTask {
+func (executor *ScheduledTaskExecutor) Schedule(task Task, delay time.Duration, options ...TaskOption) *ScheduledTask {
 	if task == nil {
 		panic("task cannot be nil")
 	}
 
-	scheduledTask := NewScheduledTask(task, executor.calculateTriggerTime(delay), 0, false)
+	scheduledTask := NewScheduledTask(task, executor.calculateTriggerTime(delay), 0, false, options...)
 	executor.addNewTask(scheduledTask)
 
 	return scheduledTask
 }
 
-func (executor *ScheduledTaskExecutor) ScheduleWithFixedDelay(task Task, initialDelay time.Duration, delay time.Duration) *ScheduledTask {
+func (executor *ScheduledTaskExecutor) ScheduleWithFixedDelay(task Task, initialDelay time.Duration, delay time.Duration, options ...TaskOption) *ScheduledTask {
 	if task == nil {
 		panic("task cannot be nil")
 	}
 
-	scheduledTask := NewScheduledTask(task, executor.calculateTriggerTime(initialDelay), delay, false)
+	scheduledTask := NewScheduledTask(task, executor.calculateTriggerTime(initialDelay), delay, false, options...)
 	executor.addNewTask(scheduledTask)
 
 	return scheduledTask
 }
 
-func (executor *ScheduledTaskExecutor) ScheduleAtWithRate(task Task, initialDelay time.Duration, period time.Duration) *ScheduledTask {
+func (executor *ScheduledTaskExecutor) ScheduleAtWithRate(task Task, initialDelay time.Duration, period time.Duration, options ...TaskOption) *ScheduledTask {
 	if task == nil {
 		panic("task cannot be nil")
 	}
 
-	scheduledTask := NewScheduledTask(task, executor.calculateTriggerTime(initialDelay), period, true)
+	scheduledTask := NewScheduledTask(task, executor.calculateTriggerTime(initialDelay), period, true, options...)
 	executor.addNewTask(scheduledTask)
 
 	return scheduledTask
 }
 
+// Shutdown stops the executor from accepting new tasks and waits for tasks
+// already running to finish, returning ctx.Err() if ctx is done first. The
+// run loop exits as soon as pending new-task/cancel sends stop racing with
+// it; already queued but not-yet-fired tasks are simply dropped.
+func (executor *ScheduledTaskExecutor) Shutdown(ctx context.Context) error {
+	executor.shutdownOnce.Do(func() {
+		// shuttingDown must flip before taskWaitGroup.Wait below is ever
+		// called: sync.WaitGroup forbids a concurrent Add once Wait has
+		// observed a zero counter, and startTask checks shuttingDown under
+		// the same lock it Adds under, so no Add can land after this point.
+		executor.taskQueueMu.Lock()
+		executor.shuttingDown = true
+		executor.taskQueueMu.Unlock()
+
+		close(executor.shutdownChannel)
+	})
+
+	done := make(chan struct{})
+
+	go func() {
+		executor.taskWaitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (executor *ScheduledTaskExecutor) calculateTriggerTime(delay time.Duration) time.Time {
 	if delay < 0 {
 		delay = 0
@@ -78,99 +168,219 @@ func (executor *ScheduledTaskExecutor) calculateTriggerTime(delay time.Duration)
 	return time.Now().Add(delay)
 }
 
+func (executor *ScheduledTaskExecutor) nextTaskId() int {
+	executor.nextSequenceMu.Lock()
+	defer executor.nextSequenceMu.Unlock()
+
+	executor.nextSequence++
+
+	return executor.nextSequence
+}
+
 func (executor *ScheduledTaskExecutor) addNewTask(task *ScheduledTask) {
-	executor.newTaskChannel <- task
+	if task.id == 0 {
+		task.id = executor.nextTaskId()
+	}
+
+	// Only set once: a periodic task is re-enqueued through this same path
+	// every time it reschedules itself, and unconditionally overwriting
+	// executor here would race with a concurrent Cancel reading it, even
+	// though the value never actually changes across reschedules.
+	if task.executor == nil {
+		task.executor = executor
+	}
+
+	select {
+	case executor.newTaskChannel <- task:
+	case <-executor.shutdownChannel:
+	}
 }
 
-func (executor *ScheduledTaskExecutor) run() {
+func (executor *ScheduledTaskExecutor) cancel(task *ScheduledTask) {
+	select {
+	case executor.cancelTaskChannel <- task:
+	case <-executor.shutdownChannel:
+	}
+}
 
-	lastClock := time.Now()
+func (executor *ScheduledTaskExecutor) run() {
 
 	for {
 
+		executor.taskQueueMu.Lock()
 		if executor.taskQueue.IsEmpty() {
 			executor.timer.Stop()
 		} else {
 			executor.timer.Reset(executor.taskQueue[0].GetDelay())
 		}
+		executor.taskQueueMu.Unlock()
 
-		for {
-			select {
-			case clock := <-executor.timer.C:
-				executor.timer.Stop()
+		select {
+		case clock := <-executor.timer.C:
+			executor.taskQueueMu.Lock()
 
-				executor.taskQueueMu.Lock()
+			var dueTasks []*ScheduledTask
 
-				for index, scheduledTask := range executor.taskQueue {
+			for !executor.taskQueue.IsEmpty() && !executor.taskQueue[0].triggerTime.After(clock) {
+				scheduledTask := executor.taskQueue[0]
 
-					if lastClock.After(scheduledTask.triggerTime) {
-						continue
-					}
+				if scheduledTask.IsFixedRate() {
+					scheduledTask.triggerTime = scheduledTask.triggerTime.Add(scheduledTask.period)
+					heap.Fix(&executor.taskQueue, 0)
+				} else {
+					heap.Pop(&executor.taskQueue)
+				}
 
-					if scheduledTask.triggerTime.After(clock) || scheduledTask.triggerTime.IsZero() {
-						break
-					}
+				dueTasks = append(dueTasks, scheduledTask)
+			}
 
-					if scheduledTask.IsFixedRate() {
-						scheduledTask.triggerTime = scheduledTask.triggerTime.Add(scheduledTask.period)
-					}
+			executor.taskQueueMu.Unlock()
 
-					if !scheduledTask.IsPeriodic() || !scheduledTask.IsFixedRate() {
-						executor.taskQueue = append(executor.taskQueue[:index], executor.taskQueue[index+1:]...)
-					}
+			// startTask must run outside taskQueueMu: it acquires
+			// taskQueueMu itself to check shuttingDown before counting the
+			// task in taskWaitGroup, and sync.Mutex is not reentrant, so
+			// holding the lock here would deadlock the run loop.
+			for _, scheduledTask := range dueTasks {
+				executor.startTask(scheduledTask)
+			}
+		case newScheduledTask := <-executor.newTaskChannel:
+			executor.taskQueueMu.Lock()
+			heap.Push(&executor.taskQueue, newScheduledTask)
+			executor.taskQueueMu.Unlock()
+		case cancelledTask := <-executor.cancelTaskChannel:
+			executor.taskQueueMu.Lock()
+			// cancelled must be set even when the task is not currently in
+			// taskQueue: a fixed-delay task sits outside the queue for the
+			// whole time its current run is in flight, and finishTask checks
+			// this flag before re-enqueuing it for its next run.
+			cancelledTask.cancelled = true
+			if cancelledTask.index >= 0 && cancelledTask.index < len(executor.taskQueue) && executor.taskQueue[cancelledTask.index] == cancelledTask {
+				heap.Remove(&executor.taskQueue, cancelledTask.index)
+			}
+			executor.taskQueueMu.Unlock()
+		case <-executor.shutdownChannel:
+			executor.timer.Stop()
+			return
+		}
+	}
+}
 
-					executor.startTask(scheduledTask)
-				}
+// startTask hands scheduledTask off to the executor's dispatch strategy on a
+// fresh goroutine: executeAndReschedule directly for a plain
+// ScheduledTaskExecutor, or the worker pool's submit for a
+// ScheduledThreadPoolExecutor. dispatch always runs off this goroutine, not
+// the run loop's, because a Block or RunInCaller overflow policy can block
+// until a worker frees up, and that worker rescheduling a fixed-delay task
+// needs the run loop free to receive on newTaskChannel — calling dispatch
+// from the run loop itself would deadlock the two on each other. A
+// WithNonConcurrent task whose previous run is still in flight is skipped
+// for this firing instead of being dispatched.
+func (executor *ScheduledTaskExecutor) startTask(scheduledTask *ScheduledTask) {
+	if scheduledTask.nonConcurrent && !scheduledTask.tryMarkRunning() {
+		return
+	}
 
-				executor.taskQueue.SorByTriggerTime()
-				lastClock = clock
+	executor.taskQueueMu.Lock()
+	if executor.shuttingDown {
+		executor.taskQueueMu.Unlock()
+		return
+	}
+	executor.taskWaitGroup.Add(1)
+	executor.taskQueueMu.Unlock()
 
-				executor.taskQueueMu.Unlock()
-			case newScheduledTask := <-executor.newTaskChannel:
-				executor.timer.Stop()
+	go executor.dispatch(scheduledTask)
+}
 
-				executor.taskQueueMu.Lock()
-				executor.taskQueue = append(executor.taskQueue, newScheduledTask)
-				executor.taskQueue.SorByTriggerTime()
-				executor.taskQueueMu.Unlock()
-			case task := <-executor.removeTaskChannel:
-				executor.timer.Stop()
+// executeAndReschedule runs scheduledTask and, once it returns, re-enqueues
+// it if it is a fixed-delay task. Every dispatch strategy must route a fired
+// task through this method exactly once, or through finishTask alone if it
+// drops the task without running it.
+func (executor *ScheduledTaskExecutor) executeAndReschedule(scheduledTask *ScheduledTask) {
+	defer executor.finishTask(scheduledTask)
 
-				executor.taskQueueMu.Lock()
+	executor.runTask(scheduledTask)
+}
 
-				taskIndex := -1
-				for index, scheduledTask := range executor.taskQueue {
-					if scheduledTask.id == task.id {
-						taskIndex = index
-						break
-					}
-				}
+// finishTask accounts for scheduledTask no longer being in flight: it
+// balances the taskWaitGroup.Add startTask made, clears the running flag
+// WithNonConcurrent relies on, and re-enqueues a fixed-delay task so its
+// next firing is scheduled period from now. executeAndReschedule calls this
+// once scheduledTask has actually run; a ScheduledThreadPoolExecutor's
+// overflow policies call it when scheduledTask is evicted or rejected
+// before it ever ran, since startTask already counted it as in flight.
+func (executor *ScheduledTaskExecutor) finishTask(scheduledTask *ScheduledTask) {
+	executor.taskWaitGroup.Done()
+
+	if scheduledTask.nonConcurrent {
+		scheduledTask.clearRunning()
+	}
 
-				executor.taskQueue = append(executor.taskQueue[:taskIndex], executor.taskQueue[taskIndex+1:]...)
-				executor.taskQueueMu.Unlock()
-			}
+	if scheduledTask.IsPeriodic() && !scheduledTask.IsFixedRate() {
+		executor.taskQueueMu.Lock()
+		cancelled := scheduledTask.cancelled
+		if !cancelled {
+			scheduledTask.triggerTime = executor.calculateTriggerTime(scheduledTask.period)
+		}
+		executor.taskQueueMu.Unlock()
 
-			break
+		if !cancelled {
+			executor.addNewTask(scheduledTask)
 		}
+	}
+}
 
+// runTask invokes scheduledTask.task with a context derived from the
+// executor's base context (see WithContext) and the task's own
+// WithTimeout/WithDeadline, and recovers a panicking task so that it cannot
+// bring down the rest of the executor. A recovered panic is reported
+// through the executor's Logger and, for fixed-rate tasks, handled
+// according to the task's ErrorPolicy.
+func (executor *ScheduledTaskExecutor) runTask(scheduledTask *ScheduledTask) {
+	ctx := executor.ctx
+
+	var cancel context.CancelFunc
+
+	switch {
+	case scheduledTask.timeout > 0:
+		ctx, cancel = context.WithTimeout(ctx, scheduledTask.timeout)
+	case !scheduledTask.deadline.IsZero():
+		ctx, cancel = context.WithDeadline(ctx, scheduledTask.deadline)
 	}
 
-}
+	if cancel != nil {
+		defer cancel()
+	}
 
-func (executor *ScheduledTaskExecutor) startTask(scheduledTask *ScheduledTask) {
-	executor.taskWaitGroup.Add(1)
+	defer func() {
+		if reason := recover(); reason != nil {
+			executor.logger.Printf("chrono: scheduled task %d panicked: %v", scheduledTask.id, reason)
+			executor.applyErrorPolicy(scheduledTask)
+		}
+	}()
 
-	go func(scheduledTask *ScheduledTask) {
-		defer func() {
-			executor.taskWaitGroup.Done()
+	scheduledTask.task(ctx)
+}
 
-			scheduledTask.triggerTime = executor.calculateTriggerTime(scheduledTask.period)
+// applyErrorPolicy reacts to a panic in scheduledTask as configured by
+// WithErrorPolicy. It only applies to fixed-rate tasks: a one-shot task
+// never runs again regardless, and a fixed-delay task is only rescheduled
+// once runTask returns, so Continue is already its behavior.
+func (executor *ScheduledTaskExecutor) applyErrorPolicy(scheduledTask *ScheduledTask) {
+	if !scheduledTask.IsFixedRate() {
+		return
+	}
 
-			if !scheduledTask.IsFixedRate() {
-				executor.newTaskChannel <- scheduledTask
-			}
-		}()
+	switch scheduledTask.errorPolicy {
+	case SkipNext:
+		executor.taskQueueMu.Lock()
+
+		if scheduledTask.index >= 0 {
+			scheduledTask.triggerTime = scheduledTask.triggerTime.Add(scheduledTask.period)
+			heap.Fix(&executor.taskQueue, scheduledTask.index)
+		}
 
-		scheduledTask.task(nil)
-	}(scheduledTask)
+		executor.taskQueueMu.Unlock()
+	case Unschedule:
+		scheduledTask.Cancel()
+	}
 }
@@ -0,0 +1,295 @@
+package chrono
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduleRunsAfterDelay(t *testing.T) {
+	executor := NewScheduledTaskExecutor()
+	defer executor.Shutdown(context.Background())
+
+	done := make(chan time.Time, 1)
+	start := time.Now()
+
+	executor.Schedule(func(ctx context.Context) {
+		done <- time.Now()
+	}, 20*time.Millisecond)
+
+	select {
+	case ran := <-done:
+		if ran.Sub(start) < 20*time.Millisecond {
+			t.Fatalf("task ran after %s, before its delay had elapsed", ran.Sub(start))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task never ran")
+	}
+}
+
+func TestScheduleWithFixedDelayWaitsForPreviousRunToFinish(t *testing.T) {
+	executor := NewScheduledTaskExecutor()
+	defer executor.Shutdown(context.Background())
+
+	var runCount int32
+	var overlapped int32
+	var running int32
+
+	task := executor.ScheduleWithFixedDelay(func(ctx context.Context) {
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+
+		time.Sleep(15 * time.Millisecond)
+		atomic.AddInt32(&runCount, 1)
+		atomic.StoreInt32(&running, 0)
+	}, 0, 5*time.Millisecond)
+	defer task.Cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&runCount) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&runCount); n < 3 {
+		t.Fatalf("fixed-delay task only ran %d times", n)
+	}
+
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Fatal("fixed-delay task ran concurrently with itself")
+	}
+}
+
+func TestCancelPreventsFurtherRuns(t *testing.T) {
+	executor := NewScheduledTaskExecutor()
+	defer executor.Shutdown(context.Background())
+
+	var runCount int32
+
+	task := executor.ScheduleWithFixedDelay(func(ctx context.Context) {
+		atomic.AddInt32(&runCount, 1)
+	}, 0, 5*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	task.Cancel()
+
+	// A run already in flight when Cancel was called, plus the cancellation
+	// itself, both need a moment to be observed by finishTask before the
+	// schedule is actually dead; give that plenty of room before sampling.
+	time.Sleep(100 * time.Millisecond)
+	n := atomic.LoadInt32(&runCount)
+	time.Sleep(50 * time.Millisecond)
+
+	if got := atomic.LoadInt32(&runCount); got != n {
+		t.Fatalf("task kept running after Cancel: count went from %d to %d", n, got)
+	}
+}
+
+func TestWithTimeoutCancelsTaskContext(t *testing.T) {
+	executor := NewScheduledTaskExecutor()
+	defer executor.Shutdown(context.Background())
+
+	errCh := make(chan error, 1)
+
+	executor.Schedule(func(ctx context.Context) {
+		<-ctx.Done()
+		errCh <- ctx.Err()
+	}, 0, WithTimeout(10*time.Millisecond))
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ctx.Err() = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task's context was never cancelled")
+	}
+}
+
+func TestWithDeadlineCancelsTaskContext(t *testing.T) {
+	executor := NewScheduledTaskExecutor()
+	defer executor.Shutdown(context.Background())
+
+	errCh := make(chan error, 1)
+	deadline := time.Now().Add(10 * time.Millisecond)
+
+	executor.Schedule(func(ctx context.Context) {
+		<-ctx.Done()
+		errCh <- ctx.Err()
+	}, 0, WithDeadline(deadline))
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ctx.Err() = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task's context was never cancelled")
+	}
+}
+
+// recordingLogger collects every Printf call so a test can assert a panic
+// was actually reported instead of silently swallowed.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (logger *recordingLogger) Printf(format string, args ...any) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	logger.messages = append(logger.messages, format)
+}
+
+func (logger *recordingLogger) count() int {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	return len(logger.messages)
+}
+
+func TestPanicIsRecoveredAndLogged(t *testing.T) {
+	logger := &recordingLogger{}
+	executor := NewScheduledTaskExecutor(WithLogger(logger))
+	defer executor.Shutdown(context.Background())
+
+	done := make(chan struct{})
+
+	executor.Schedule(func(ctx context.Context) {
+		defer close(done)
+		panic("boom")
+	}, 0)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("panicking task never returned control to the executor")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if logger.count() != 1 {
+		t.Fatalf("logger recorded %d messages, want 1", logger.count())
+	}
+}
+
+func TestErrorPolicySkipNextSkipsOneFiring(t *testing.T) {
+	executor := NewScheduledTaskExecutor(WithLogger(&recordingLogger{}))
+	defer executor.Shutdown(context.Background())
+
+	var runTimes []time.Time
+	var mu sync.Mutex
+	first := true
+
+	const period = 30 * time.Millisecond
+
+	task := executor.ScheduleAtWithRate(func(ctx context.Context) {
+		mu.Lock()
+		runTimes = append(runTimes, time.Now())
+		shouldPanic := first
+		first = false
+		mu.Unlock()
+
+		if shouldPanic {
+			panic("boom")
+		}
+	}, 5*time.Millisecond, period, WithErrorPolicy(SkipNext))
+	defer task.Cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(runTimes)
+		mu.Unlock()
+
+		if n >= 2 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("task did not run at least twice")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	mu.Lock()
+	gap := runTimes[1].Sub(runTimes[0])
+	mu.Unlock()
+
+	// Skipping one firing should push the gap close to 2*period; allow some
+	// slack for timer granularity while still ruling out a plain period gap.
+	if minGap := period + period/2; gap < minGap {
+		t.Fatalf("second run arrived after %s, want at least %s (one skipped firing)", gap, minGap)
+	}
+}
+
+func TestErrorPolicyUnscheduleCancelsTask(t *testing.T) {
+	executor := NewScheduledTaskExecutor(WithLogger(&recordingLogger{}))
+	defer executor.Shutdown(context.Background())
+
+	var runCount int32
+
+	executor.ScheduleAtWithRate(func(ctx context.Context) {
+		atomic.AddInt32(&runCount, 1)
+		panic("boom")
+	}, 0, 10*time.Millisecond, WithErrorPolicy(Unschedule))
+
+	time.Sleep(50 * time.Millisecond)
+	n := atomic.LoadInt32(&runCount)
+
+	if n == 0 {
+		t.Fatal("task never ran")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if atomic.LoadInt32(&runCount) != n {
+		t.Fatalf("task kept running after a panic with WithErrorPolicy(Unschedule): ran %d more times", atomic.LoadInt32(&runCount)-n)
+	}
+}
+
+func TestShutdownWaitsForInFlightTasks(t *testing.T) {
+	executor := NewScheduledTaskExecutor()
+
+	started := make(chan struct{})
+	var finished int32
+
+	executor.Schedule(func(ctx context.Context) {
+		close(started)
+		time.Sleep(30 * time.Millisecond)
+		atomic.StoreInt32(&finished, 1)
+	}, 0)
+
+	<-started
+
+	if err := executor.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+
+	if atomic.LoadInt32(&finished) == 0 {
+		t.Fatal("Shutdown returned before the in-flight task finished")
+	}
+}
+
+func TestShutdownReturnsCtxErrWhenContextExpiresFirst(t *testing.T) {
+	executor := NewScheduledTaskExecutor()
+	defer executor.Shutdown(context.Background())
+
+	executor.Schedule(func(ctx context.Context) {
+		time.Sleep(200 * time.Millisecond)
+	}, 0)
+
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := executor.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown returned %v, want context.DeadlineExceeded", err)
+	}
+}
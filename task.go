@@ -2,31 +2,112 @@ package chrono
 
 import (
 	"context"
-	"sort"
+	"sync/atomic"
 	"time"
 )
 
 type Task func(ctx context.Context)
 
+// ErrorPolicy controls how a fixed-rate task's schedule is affected by a
+// panic recovered from it.
+type ErrorPolicy int
+
+const (
+	// Continue leaves the schedule untouched; the task runs again at its
+	// next regular trigger time. This is the default.
+	Continue ErrorPolicy = iota
+	// SkipNext skips the task's next scheduled run in addition to the one
+	// that just failed.
+	SkipNext
+	// Unschedule cancels the task so that it does not run again.
+	Unschedule
+)
+
 type ScheduledTask struct {
-	id          int
-	task        Task
-	triggerTime time.Time
-	period      time.Duration
-	fixedRate   bool
+	id            int
+	index         int
+	task          Task
+	triggerTime   time.Time
+	period        time.Duration
+	fixedRate     bool
+	timeout       time.Duration
+	deadline      time.Time
+	errorPolicy   ErrorPolicy
+	nonConcurrent bool
+	running       int32
+	cancelled     bool
+	executor      *ScheduledTaskExecutor
+}
+
+// tryMarkRunning reports whether the task was idle and has now been marked
+// as running; it returns false if the task was already running, which is
+// what WithNonConcurrent uses to suppress a re-entrant run.
+func (scheduledTask *ScheduledTask) tryMarkRunning() bool {
+	return atomic.CompareAndSwapInt32(&scheduledTask.running, 0, 1)
+}
+
+func (scheduledTask *ScheduledTask) clearRunning() {
+	atomic.StoreInt32(&scheduledTask.running, 0)
 }
 
-func NewScheduledTask(task Task, triggerTime time.Time, period time.Duration, fixedRate bool) *ScheduledTask {
+// TaskOption configures a single ScheduledTask at construction time.
+type TaskOption func(task *ScheduledTask)
+
+// WithTimeout derives the context.Context passed to the task from
+// context.WithTimeout, so the task observes ctx.Done() after d has elapsed.
+// It takes precedence over WithDeadline if both are given.
+func WithTimeout(d time.Duration) TaskOption {
+	return func(task *ScheduledTask) {
+		task.timeout = d
+	}
+}
+
+// WithDeadline derives the context.Context passed to the task from
+// context.WithDeadline, so the task observes ctx.Done() once deadline
+// passes.
+func WithDeadline(deadline time.Time) TaskOption {
+	return func(task *ScheduledTask) {
+		task.deadline = deadline
+	}
+}
+
+// WithErrorPolicy sets how a fixed-rate task's schedule reacts to a
+// recovered panic; it has no effect on one-shot or fixed-delay tasks.
+func WithErrorPolicy(policy ErrorPolicy) TaskOption {
+	return func(task *ScheduledTask) {
+		task.errorPolicy = policy
+	}
+}
+
+// WithNonConcurrent suppresses a fixed-rate task from starting a new run
+// while a previous run of the same task is still in flight, rather than
+// letting runs pile up when a task regularly takes longer than its period.
+// It has no effect on one-shot or fixed-delay tasks, which already never
+// overlap themselves.
+func WithNonConcurrent() TaskOption {
+	return func(task *ScheduledTask) {
+		task.nonConcurrent = true
+	}
+}
+
+func NewScheduledTask(task Task, triggerTime time.Time, period time.Duration, fixedRate bool, options ...TaskOption) *ScheduledTask {
 	if period < 0 {
 		period = 0
 	}
 
-	return &ScheduledTask{
+	scheduledTask := &ScheduledTask{
+		index:       -1,
 		task:        task,
 		triggerTime: triggerTime,
 		period:      period,
 		fixedRate:   fixedRate,
 	}
+
+	for _, option := range options {
+		option(scheduledTask)
+	}
+
+	return scheduledTask
 }
 
 func (scheduledTask *ScheduledTask) GetDelay() time.Duration {
@@ -41,6 +122,24 @@ func (scheduledTask *ScheduledTask) IsFixedRate() bool {
 	return scheduledTask.fixedRate
 }
 
+// Cancel removes the task from its executor's queue in O(log n), so that it
+// will not fire again. Cancelling a task that has already fired or already
+// been cancelled is a no-op; cancelling a fixed-delay task while its current
+// run is still in flight (so it is not sitting in the queue to be removed)
+// still prevents it being rescheduled once that run finishes.
+func (scheduledTask *ScheduledTask) Cancel() {
+	if scheduledTask.executor == nil {
+		return
+	}
+
+	scheduledTask.executor.cancel(scheduledTask)
+}
+
+// ScheduledTaskQueue is a container/heap priority queue of *ScheduledTask
+// ordered by triggerTime, so the next task to fire is always at index 0.
+// index on each ScheduledTask is kept in sync by Swap/Push/Pop, which is
+// what lets ScheduledTask.Cancel remove an arbitrary task in O(log n)
+// instead of scanning the whole queue.
 type ScheduledTaskQueue []*ScheduledTask
 
 func (queue ScheduledTaskQueue) IsEmpty() bool {
@@ -53,16 +152,36 @@ func (queue ScheduledTaskQueue) Len() int {
 
 func (queue ScheduledTaskQueue) Swap(i, j int) {
 	queue[i], queue[j] = queue[j], queue[i]
+	queue[i].index = i
+	queue[j].index = j
 }
 
 func (queue ScheduledTaskQueue) Less(i, j int) bool {
 	return queue[i].triggerTime.Before(queue[j].triggerTime)
 }
 
-func (queue ScheduledTaskQueue) SorByTriggerTime() {
-	sort.Sort(queue)
+func (queue *ScheduledTaskQueue) Push(value any) {
+	scheduledTask := value.(*ScheduledTask)
+	scheduledTask.index = len(*queue)
+	*queue = append(*queue, scheduledTask)
+}
+
+func (queue *ScheduledTaskQueue) Pop() any {
+	old := *queue
+	n := len(old)
+
+	scheduledTask := old[n-1]
+	old[n-1] = nil
+	scheduledTask.index = -1
+	*queue = old[:n-1]
+
+	return scheduledTask
 }
 
+// TimeFunction produces a time.Time, used by WithStartTime so that the
+// start time can be evaluated lazily at scheduling time.
+type TimeFunction func() time.Time
+
 type SchedulerTask struct {
 	task      Task
 	startTime time.Time
@@ -74,59 +193,93 @@ func NewSchedulerTask(task Task, options ...Option) *SchedulerTask {
 		panic("task cannot be nil")
 	}
 
-	runnableTask := &SchedulerTask{
-		task:      task,
-		startTime: time.Time{},
-		location:  time.Local,
-	}
+	schedulingOptions := &schedulingOptions{location: time.Local}
 
 	for _, option := range options {
-		option(runnableTask)
+		option(schedulingOptions)
 	}
 
-	return runnableTask
+	return &SchedulerTask{
+		task:      task,
+		startTime: schedulingOptions.startTime,
+		location:  schedulingOptions.location,
+	}
 }
 
-type Option func(task *SchedulerTask)
+// schedulingOptions holds the settings shared by SchedulerTask and
+// CronTrigger, so that WithStartTime and WithLocation can be applied to
+// either one through the same Option type.
+type schedulingOptions struct {
+	startTime time.Time
+	location  *time.Location
+}
+
+type Option func(options *schedulingOptions)
 
 func WithStartTime(startTime TimeFunction) Option {
-	return func(task *SchedulerTask) {
-		task.startTime = startTime()
+	return func(options *schedulingOptions) {
+		options.startTime = startTime()
 	}
 }
 
 func WithLocation(location string) Option {
-	return func(task *SchedulerTask) {
+	return func(options *schedulingOptions) {
 		loadedLocation, err := time.LoadLocation(location)
 
 		if err != nil {
 			panic(err)
 		}
 
-		task.location = loadedLocation
+		options.location = loadedLocation
 	}
 }
 
+// ReschedulableTask repeatedly schedules task on executor, re-evaluating
+// trigger after every run to compute the next execution time. Unlike the
+// fixed-delay/fixed-rate tasks scheduled directly through ScheduledExecutor,
+// the interval between runs can vary, which is what a Trigger such as
+// CronTrigger needs.
 type ReschedulableTask struct {
 	executor ScheduledExecutor
+	task     Task
 	trigger  Trigger
 }
 
-func NewReschedulableTask(executor ScheduledExecutor, trigger Trigger) *ReschedulableTask {
+func NewReschedulableTask(executor ScheduledExecutor, task Task, trigger Trigger) *ReschedulableTask {
 	if executor == nil {
 		panic("executor cannot be nil")
 	}
 
-	if trigger != nil {
+	if task == nil {
+		panic("task cannot be nil")
+	}
+
+	if trigger == nil {
 		panic("trigger cannot be nil")
 	}
 
 	return &ReschedulableTask{
 		executor,
+		task,
 		trigger,
 	}
 }
 
-func (task *ReschedulableTask) Schedule() *ScheduledTask {
-	return nil
+// Schedule computes the next execution time from trigger and enqueues task
+// on the executor to run at that time. After the task runs, it reschedules
+// itself against the trigger again, so a single call to Schedule keeps the
+// task firing for as long as the trigger keeps producing execution times.
+func (reschedulableTask *ReschedulableTask) Schedule() *ScheduledTask {
+	nextTime := reschedulableTask.trigger.NextExecutionTime(time.Now())
+
+	if nextTime.IsZero() {
+		return nil
+	}
+
+	return reschedulableTask.executor.Schedule(reschedulableTask.run, nextTime.Sub(time.Now()))
+}
+
+func (reschedulableTask *ReschedulableTask) run(ctx context.Context) {
+	reschedulableTask.task(ctx)
+	reschedulableTask.Schedule()
 }
@@ -0,0 +1,71 @@
+package chrono
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+)
+
+// newBenchmarkQueue builds a ScheduledTaskQueue of n tasks with staggered
+// trigger times, as heap.Push would have left it after n individual
+// schedules.
+func newBenchmarkQueue(n int) ScheduledTaskQueue {
+	queue := make(ScheduledTaskQueue, 0, n)
+
+	now := time.Now()
+
+	for i := 0; i < n; i++ {
+		heap.Push(&queue, &ScheduledTask{
+			triggerTime: now.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+
+	return queue
+}
+
+func BenchmarkScheduledTaskQueuePush(b *testing.B) {
+	const n = 10000
+
+	queue := newBenchmarkQueue(n)
+	now := time.Now()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		heap.Push(&queue, &ScheduledTask{triggerTime: now})
+		heap.Pop(&queue)
+	}
+}
+
+func BenchmarkScheduledTaskQueuePop(b *testing.B) {
+	const n = 10000
+
+	b.StopTimer()
+
+	for i := 0; i < b.N; i++ {
+		queue := newBenchmarkQueue(n)
+
+		b.StartTimer()
+		heap.Pop(&queue)
+		b.StopTimer()
+	}
+}
+
+// BenchmarkScheduledTaskCancel measures cancelling a task out of the middle
+// of a 10k+ entry queue via heap.Remove, which is what ScheduledTask.Cancel
+// relies on to run in O(log n) instead of the linear scan the queue used
+// before it was backed by container/heap.
+func BenchmarkScheduledTaskCancel(b *testing.B) {
+	const n = 10000
+
+	b.StopTimer()
+
+	for i := 0; i < b.N; i++ {
+		queue := newBenchmarkQueue(n)
+		target := queue[n/2]
+
+		b.StartTimer()
+		heap.Remove(&queue, target.index)
+		b.StopTimer()
+	}
+}
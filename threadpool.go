@@ -0,0 +1,134 @@
+package chrono
+
+// OverflowPolicy decides what happens when a fired task arrives at a
+// ScheduledThreadPoolExecutor whose worker pool is already saturated.
+type OverflowPolicy int
+
+const (
+	// Block waits until a worker becomes free. The wait happens on the
+	// goroutine startTask started for this firing, not on the run loop. This
+	// is the default.
+	Block OverflowPolicy = iota
+	// DropOldest evicts the longest-waiting queued task to make room for the
+	// new one.
+	DropOldest
+	// Reject drops the new task and reports it through the executor's
+	// Logger, leaving everything already queued untouched.
+	Reject
+	// RunInCaller runs the task synchronously on the goroutine startTask
+	// started for this firing instead of a worker, mirroring
+	// java.util.concurrent's CallerRunsPolicy. The run loop keeps running
+	// meanwhile, and no work is dropped.
+	RunInCaller
+)
+
+// ScheduledThreadPoolExecutor is a ScheduledTaskExecutor that dispatches
+// fired tasks onto a fixed pool of worker goroutines, so at most poolSize
+// tasks run on workers at once. Each firing is still handed to the pool from
+// its own goroutine (see startTask); under Block that goroutine waits for a
+// free worker, so the number of goroutines waiting for a worker is not
+// bounded.
+type ScheduledThreadPoolExecutor struct {
+	*ScheduledTaskExecutor
+
+	workChannel chan *ScheduledTask
+	overflow    OverflowPolicy
+}
+
+// ThreadPoolOption configures a ScheduledThreadPoolExecutor at construction
+// time.
+type ThreadPoolOption func(executor *ScheduledThreadPoolExecutor)
+
+// WithOverflowPolicy sets how the pool behaves when a fired task arrives and
+// every worker is busy. The default is Block.
+func WithOverflowPolicy(policy OverflowPolicy) ThreadPoolOption {
+	return func(executor *ScheduledThreadPoolExecutor) {
+		executor.overflow = policy
+	}
+}
+
+// NewScheduledThreadPoolExecutor starts poolSize worker goroutines and
+// returns an executor that dispatches every fired task onto them, subject to
+// its OverflowPolicy. Handing a firing to the pool still takes one
+// goroutine per firing (see startTask).
+func NewScheduledThreadPoolExecutor(poolSize int, options ...ThreadPoolOption) *ScheduledThreadPoolExecutor {
+	if poolSize <= 0 {
+		panic("poolSize must be greater than zero")
+	}
+
+	threadPoolExecutor := &ScheduledThreadPoolExecutor{
+		ScheduledTaskExecutor: NewScheduledTaskExecutor(),
+		workChannel:           make(chan *ScheduledTask, poolSize),
+	}
+
+	for _, option := range options {
+		option(threadPoolExecutor)
+	}
+
+	threadPoolExecutor.dispatch = threadPoolExecutor.submit
+
+	for i := 0; i < poolSize; i++ {
+		go threadPoolExecutor.worker()
+	}
+
+	return threadPoolExecutor
+}
+
+func (executor *ScheduledThreadPoolExecutor) worker() {
+	for scheduledTask := range executor.workChannel {
+		executor.executeAndReschedule(scheduledTask)
+	}
+}
+
+// submit hands scheduledTask to the worker pool according to the executor's
+// OverflowPolicy once every worker is busy.
+func (executor *ScheduledThreadPoolExecutor) submit(scheduledTask *ScheduledTask) {
+	switch executor.overflow {
+	case DropOldest:
+		select {
+		case executor.workChannel <- scheduledTask:
+			return
+		default:
+		}
+
+		select {
+		case evicted := <-executor.workChannel:
+			executor.drop(evicted)
+		default:
+		}
+
+		select {
+		case executor.workChannel <- scheduledTask:
+		default:
+			executor.logger.Printf("chrono: dropped scheduled task %d, pool still saturated after evicting oldest", scheduledTask.id)
+			executor.drop(scheduledTask)
+		}
+	case Reject:
+		select {
+		case executor.workChannel <- scheduledTask:
+		default:
+			executor.logger.Printf("chrono: rejected scheduled task %d, worker pool saturated", scheduledTask.id)
+			executor.drop(scheduledTask)
+		}
+	case RunInCaller:
+		select {
+		case executor.workChannel <- scheduledTask:
+		default:
+			executor.executeAndReschedule(scheduledTask)
+		}
+	default:
+		executor.workChannel <- scheduledTask
+	}
+}
+
+// drop accounts for scheduledTask never being run after startTask already
+// counted it in taskWaitGroup and, for a WithNonConcurrent task, marked it
+// running: without this, a dropped task leaves taskWaitGroup over-counted
+// (so Shutdown never returns), its running flag stuck at 1 (so it is
+// silently never dispatched again), and a fixed-delay task's schedule dead
+// for good, since it exists only as the in-flight reference being dropped
+// here. finishTask handles all three the same way it would if the task had
+// actually run.
+func (executor *ScheduledThreadPoolExecutor) drop(scheduledTask *ScheduledTask) {
+	executor.finishTask(scheduledTask)
+}
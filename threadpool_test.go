@@ -0,0 +1,213 @@
+package chrono
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThreadPoolExecutorRunsTasks(t *testing.T) {
+	executor := NewScheduledThreadPoolExecutor(2)
+	defer executor.Shutdown(context.Background())
+
+	done := make(chan struct{})
+
+	executor.Schedule(func(ctx context.Context) {
+		close(done)
+	}, 0)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("task never ran")
+	}
+}
+
+// TestBlockPolicyDoesNotDeadlockUnderSaturation saturates a single-worker
+// pool with fixed-delay tasks under the default Block policy: the goroutines
+// dispatching fired tasks block on workChannel while a worker's reschedule
+// needs run free to receive on newTaskChannel, and the pool must keep making
+// progress through that without deadlocking.
+func TestBlockPolicyDoesNotDeadlockUnderSaturation(t *testing.T) {
+	executor := NewScheduledThreadPoolExecutor(1)
+	defer executor.Shutdown(context.Background())
+
+	var ran int32
+
+	for i := 0; i < 5; i++ {
+		task := executor.ScheduleWithFixedDelay(func(ctx context.Context) {
+			atomic.AddInt32(&ran, 1)
+		}, 0, 10*time.Millisecond)
+		defer task.Cancel()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&ran) < 20 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&ran); n < 20 {
+		t.Fatalf("pool wedged: only %d runs observed before timing out", n)
+	}
+}
+
+func TestRejectPolicyDropsWithoutWedgingShutdown(t *testing.T) {
+	logger := &recordingLogger{}
+	executor := NewScheduledThreadPoolExecutor(1, WithOverflowPolicy(Reject))
+	executor.logger = logger
+
+	block := make(chan struct{})
+
+	// The pool's single worker picks up this one immediately, leaving it
+	// running rather than sitting in workChannel.
+	executor.Schedule(func(ctx context.Context) {
+		<-block
+	}, 0)
+
+	time.Sleep(10 * time.Millisecond)
+
+	// This one fills workChannel's one-slot buffer, since the worker is busy
+	// with the task above.
+	executor.Schedule(func(ctx context.Context) {
+		<-block
+	}, 0)
+
+	time.Sleep(10 * time.Millisecond)
+
+	// With the worker busy and the buffer full, this firing has nowhere to
+	// go and must be rejected.
+	var rejectedRan int32
+	executor.Schedule(func(ctx context.Context) {
+		atomic.AddInt32(&rejectedRan, 1)
+	}, 0)
+
+	time.Sleep(10 * time.Millisecond)
+	close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := executor.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned error: %v, want nil (dropped task's taskWaitGroup entry must be balanced)", err)
+	}
+
+	if atomic.LoadInt32(&rejectedRan) != 0 {
+		t.Fatal("rejected task ran anyway")
+	}
+
+	if logger.count() == 0 {
+		t.Fatal("Reject did not report the dropped task through the Logger")
+	}
+}
+
+func TestDropOldestReEnqueuesFixedDelayTask(t *testing.T) {
+	executor := NewScheduledThreadPoolExecutor(1, WithOverflowPolicy(DropOldest))
+	defer executor.Shutdown(context.Background())
+
+	block := make(chan struct{})
+
+	// Occupy the pool's single worker so the next firing has nowhere to run.
+	executor.Schedule(func(ctx context.Context) {
+		<-block
+	}, 0)
+
+	var ran int32
+
+	task := executor.ScheduleWithFixedDelay(func(ctx context.Context) {
+		atomic.AddInt32(&ran, 1)
+	}, 5*time.Millisecond, 10*time.Millisecond)
+	defer task.Cancel()
+
+	// Fires while the fixed-delay task is still waiting in workChannel's
+	// one-slot buffer, so DropOldest evicts the fixed-delay task to make room.
+	executor.Schedule(func(ctx context.Context) {}, 15*time.Millisecond)
+
+	// Give the fixed-delay task a chance to fire and be evicted/dropped while
+	// the worker is still busy with the blocking task above.
+	time.Sleep(30 * time.Millisecond)
+	close(block)
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&ran) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if atomic.LoadInt32(&ran) == 0 {
+		t.Fatal("fixed-delay task's schedule died after being dropped by DropOldest instead of being re-enqueued")
+	}
+}
+
+func TestRunInCallerRunsSynchronouslyWithoutDeadlock(t *testing.T) {
+	executor := NewScheduledThreadPoolExecutor(1, WithOverflowPolicy(RunInCaller))
+	defer executor.Shutdown(context.Background())
+
+	block := make(chan struct{})
+
+	executor.Schedule(func(ctx context.Context) {
+		<-block
+	}, 0)
+
+	time.Sleep(10 * time.Millisecond)
+
+	// Fills workChannel's one-slot buffer, since the worker is busy with the
+	// task above, so the next firing finds the pool saturated.
+	executor.Schedule(func(ctx context.Context) {
+		<-block
+	}, 0)
+
+	time.Sleep(10 * time.Millisecond)
+
+	var ran int32
+	done := make(chan struct{})
+
+	executor.Schedule(func(ctx context.Context) {
+		atomic.AddInt32(&ran, 1)
+		close(done)
+	}, 0)
+
+	close(block)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunInCaller task never ran")
+	}
+
+	if atomic.LoadInt32(&ran) != 1 {
+		t.Fatal("RunInCaller task did not run exactly once")
+	}
+}
+
+func TestThreadPoolWithNonConcurrentSuppressesOverlap(t *testing.T) {
+	executor := NewScheduledThreadPoolExecutor(4)
+	defer executor.Shutdown(context.Background())
+
+	var running int32
+	var overlapped int32
+	var runCount int32
+
+	task := executor.ScheduleAtWithRate(func(ctx context.Context) {
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&runCount, 1)
+		atomic.StoreInt32(&running, 0)
+	}, 0, 5*time.Millisecond, WithNonConcurrent())
+	defer task.Cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&runCount) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if n := atomic.LoadInt32(&runCount); n < 3 {
+		t.Fatalf("non-concurrent fixed-rate task only ran %d times", n)
+	}
+
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Fatal("WithNonConcurrent task ran concurrently with itself on the pool")
+	}
+}
@@ -0,0 +1,49 @@
+package chrono
+
+import "time"
+
+// Trigger computes the next time a ReschedulableTask should fire, given the
+// instant it is asked from. Implementations may return the zero time.Time to
+// signal that the task should no longer be rescheduled.
+type Trigger interface {
+	NextExecutionTime(t time.Time) time.Time
+}
+
+type cronTrigger struct {
+	expression *CronExpression
+	location   *time.Location
+}
+
+// CronTrigger parses a 6-field cron expression (second minute hour
+// day-of-month month day-of-week) and returns a Trigger that fires at every
+// matching instant. The expression is evaluated in the location configured
+// via WithLocation (time.Local by default), mirroring the WithLocation
+// option already present on SchedulerTask, so that schedules such as "every
+// day at 02:30 America/New_York" keep firing at the right wall-clock time
+// across DST transitions regardless of the process's own time zone.
+//
+// CronTrigger panics if expression cannot be parsed, consistent with the
+// other constructors in this package that treat a malformed schedule as a
+// programmer error.
+func CronTrigger(expression string, options ...Option) Trigger {
+	cronExpression, err := ParseCronExpression(expression)
+
+	if err != nil {
+		panic(err)
+	}
+
+	schedulingOptions := &schedulingOptions{location: time.Local}
+
+	for _, option := range options {
+		option(schedulingOptions)
+	}
+
+	return &cronTrigger{
+		expression: cronExpression,
+		location:   schedulingOptions.location,
+	}
+}
+
+func (trigger *cronTrigger) NextExecutionTime(t time.Time) time.Time {
+	return trigger.expression.NextTime(t.In(trigger.location))
+}